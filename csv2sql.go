@@ -1,27 +1,78 @@
 package main
 
 import (
+	"archive/zip"
 	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
 	"database/sql"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/klauspost/compress/zstd"
+	"github.com/mattn/go-sqlite3"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	textunicode "golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
 )
 
 var (
-	HeadersOn      = true
-	Replacing      = false
-	WritePlainText = false
+	// HeadersOn controls whether query output prints a header row/line. An
+	// explicit --headers/--no-headers sets it directly; auto-detecting
+	// whether a particular input CSV has a header row of its own does not
+	// touch it — see inputHasHeader in createTableFromReader.
+	HeadersOn        = true
+	Replacing        = false
+	OutputFormat     = "csv"
+	InferTypes       = false
+	SampleRows       = 0 // 0 means scan every row
+	SchemaOverride   = map[string]string{}
+	BatchSize        = 1000
+	DelimOverride    = ""
+	EncodingOverride = ""
+	SniffBytes       = 65536
+	ZipMember        = ""
+	VirtualMode      = false
+	Params           = map[string]string{}
 )
 
+// sqliteDriverName is registered with a ConnectHook so every connection has
+// the "csv" virtual-table module available, whether or not --virtual is used
+// (a raw "CREATE VIRTUAL TABLE ... USING csv(...)" query can reach it too).
+// registerCSVModule is only a real registration when built with
+// -tags sqlite_vtable, since that's how go-sqlite3 gates vtable support;
+// see csvvtab.go and csvvtab_stub.go.
+const sqliteDriverName = "sqlite3_csv2sql"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: registerCSVModule,
+	})
+}
+
+// headersOverride tracks an explicit --headers/--no-headers flag. When nil,
+// whether a CSV has a header row is auto-detected per file instead.
+var headersOverride *bool
+
+var validFormats = map[string]bool{
+	"csv": true, "tsv": true, "json": true, "jsonl": true, "markdown": true, "table": true,
+}
+
 func main() {
-	db, err := sql.Open("sqlite3", "file::memory:")
+	db, err := sql.Open(sqliteDriverName, "file::memory:")
 	if err != nil {
 		log.Fatalf("error opening in-memory database: %v", err)
 	}
@@ -32,7 +83,13 @@ func main() {
 		arg := os.Args[i]
 		switch {
 		case arg == "--plain-text" || arg == "--plain" || arg == "--text":
-			WritePlainText = true
+			OutputFormat = "tsv"
+		case strings.HasPrefix(arg, "--format="):
+			format := strings.TrimPrefix(arg, "--format=")
+			if !validFormats[format] {
+				log.Fatalf("unknown --format value %q", format)
+			}
+			OutputFormat = format
 		case arg == "--replace":
 			Replacing = true
 		case arg == "--db":
@@ -44,10 +101,11 @@ func main() {
 			if !strings.HasSuffix(dbName, ".db") {
 				dbName += ".db"
 			}
-			db, err = sql.Open("sqlite3", dbName)
+			db, err = sql.Open(sqliteDriverName, dbName)
 			if err != nil {
 				log.Fatalf("error opening database %s: %v", os.Args[i], err)
 			}
+			setBulkLoadPragmas(db)
 		case arg == "--table":
 			if i+1 >= len(os.Args) {
 				log.Fatalf("--table requires an argument")
@@ -55,26 +113,70 @@ func main() {
 			i++
 			tableName = os.Args[i]
 		case arg == "--no-headers" || arg == "--no-header":
+			off := false
+			headersOverride = &off
 			HeadersOn = false
 		case arg == "--headers":
+			on := true
+			headersOverride = &on
 			HeadersOn = true
-		case strings.HasSuffix(arg, ".csv"):
-			fileName := arg
+		case strings.HasPrefix(arg, "--delim="):
+			DelimOverride = strings.TrimPrefix(arg, "--delim=")
+		case strings.HasPrefix(arg, "--encoding="):
+			EncodingOverride = strings.TrimPrefix(arg, "--encoding=")
+		case strings.HasPrefix(arg, "--sniff-bytes="):
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "--sniff-bytes="))
+			if err != nil {
+				log.Fatalf("invalid --sniff-bytes value %q: %v", arg, err)
+			}
+			SniffBytes = n
+		case arg == "--infer-types":
+			InferTypes = true
+		case strings.HasPrefix(arg, "--sample-rows="):
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "--sample-rows="))
+			if err != nil {
+				log.Fatalf("invalid --sample-rows value %q: %v", arg, err)
+			}
+			SampleRows = n
+			InferTypes = true
+		case strings.HasPrefix(arg, "--batch="):
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "--batch="))
+			if err != nil {
+				log.Fatalf("invalid --batch value %q: %v", arg, err)
+			}
+			BatchSize = n
+		case arg == "--schema":
+			if i+1 >= len(os.Args) {
+				log.Fatalf("--schema requires an argument")
+			}
+			i++
+			parseSchemaOverride(os.Args[i])
+		case arg == "--param":
+			if i+1 >= len(os.Args) {
+				log.Fatalf("--param requires an argument")
+			}
+			i++
+			parseParamOverride(os.Args[i])
+		case strings.HasPrefix(arg, "--zip-member="):
+			ZipMember = strings.TrimPrefix(arg, "--zip-member=")
+		case arg == "--virtual":
+			VirtualMode = true
+		case isCSVInputArg(arg):
+			spec := arg
 			if tableName == "" {
-				tableName = sqlNameFromString(fileName)
+				tableName = sqlNameFromString(spec)
 			}
-			createTableFromCSV(db, tableName, fileName)
+			createTableFromCSV(db, tableName, spec)
 			tableName = "" // reset table name
 		case strings.HasSuffix(arg, ".sql"):
-			query := readSQLFile(arg)
-			executeQuery(db, query)
+			executeScript(db, readSQLFile(arg))
 		case arg == "stdin":
 			if tableName == "" {
 				tableName = "stdin"
 			}
 			createTableFromReader(db, tableName, os.Stdin, "stdin")
-		case strings.HasPrefix(arg, "select"):
-			executeQuery(db, arg)
+		case strings.HasPrefix(arg, "select") || strings.HasPrefix(arg, "."):
+			executeScript(db, arg)
 		default:
 			log.Fatalf("unknown argument: %s", arg)
 		}
@@ -89,9 +191,163 @@ func readSQLFile(fileName string) string {
 	return string(contents)
 }
 
-func executeQuery(db *sql.DB, query string) {
+// executeScript splits script into individual statements and dot-commands
+// and runs each in turn: dot-commands are handled locally, statements that
+// return rows (SELECT/WITH/PRAGMA/EXPLAIN/VALUES) go through the writer
+// path, and everything else (DDL/DML) runs via db.Exec. Any :name reference
+// that matches a --param is bound as a named parameter.
+func executeScript(db *sql.DB, script string) {
+	for _, raw := range splitSQLStatements(script) {
+		stmt := strings.TrimSpace(raw)
+		if stmt == "" {
+			continue
+		}
+		if strings.HasPrefix(stmt, ".") {
+			runDotCommand(db, stmt)
+			continue
+		}
+
+		args := bindParams(stmt)
+		if returnsRows(stmt) {
+			executeQuery(db, stmt, args...)
+			continue
+		}
+		if _, err := db.Exec(stmt, args...); err != nil {
+			log.Fatalf("error executing statement %#v: %v", stmt, err)
+		}
+	}
+}
+
+// splitSQLStatements splits a script on unquoted ';', skipping over
+// '...'/"..." strings (with ” as an escaped quote) and --/* */ comments,
+// so a semicolon inside a string literal or a comment doesn't end a
+// statement early.
+func splitSQLStatements(script string) []string {
+	var statements []string
+	var cur strings.Builder
 
-	rows, err := db.Query(query)
+	runes := []rune(script)
+	n := len(runes)
+	for i := 0; i < n; i++ {
+		c := runes[i]
+		switch {
+		case c == '.' && strings.TrimSpace(cur.String()) == "" && (i == 0 || runes[i-1] == '\n'):
+			// A dot-command runs to end of line rather than to the next ';',
+			// matching the sqlite3 CLI.
+			start := i
+			for i < n && runes[i] != '\n' {
+				i++
+			}
+			statements = append(statements, string(runes[start:i]))
+			cur.Reset()
+		case c == '\'' || c == '"':
+			quote := c
+			cur.WriteRune(c)
+			i++
+			for i < n {
+				cur.WriteRune(runes[i])
+				if runes[i] == quote {
+					if quote == '\'' && i+1 < n && runes[i+1] == '\'' {
+						i++
+						cur.WriteRune(runes[i])
+					} else {
+						break
+					}
+				}
+				i++
+			}
+		case c == '-' && i+1 < n && runes[i+1] == '-':
+			for i < n && runes[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < n && runes[i+1] == '*':
+			i += 2
+			for i+1 < n && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == ';':
+			statements = append(statements, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	if strings.TrimSpace(cur.String()) != "" {
+		statements = append(statements, cur.String())
+	}
+	return statements
+}
+
+// returnsRows reports whether a statement is expected to produce a result
+// set and so should go through the writer path rather than db.Exec.
+func returnsRows(stmt string) bool {
+	fields := strings.Fields(stmt)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH", "PRAGMA", "EXPLAIN", "VALUES":
+		return true
+	default:
+		return false
+	}
+}
+
+// bindParams returns a sql.Named arg for every --param whose :name actually
+// appears in stmt, so unreferenced params aren't sent to the driver.
+func bindParams(stmt string) []interface{} {
+	var args []interface{}
+	for name, value := range Params {
+		if paramRefPattern(name).MatchString(stmt) {
+			args = append(args, sql.Named(name, value))
+		}
+	}
+	return args
+}
+
+func paramRefPattern(name string) *regexp.Regexp {
+	return regexp.MustCompile(":" + regexp.QuoteMeta(name) + `\b`)
+}
+
+// parseParamOverride parses a "name=value" --param spec into Params.
+func parseParamOverride(spec string) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 {
+		log.Fatalf("invalid --param entry %q, expected name=value", spec)
+	}
+	Params[parts[0]] = parts[1]
+}
+
+// runDotCommand handles the small set of sqlite3-CLI-style dot-commands
+// this tool understands, without sending them to SQLite.
+func runDotCommand(db *sql.DB, cmd string) {
+	fields := strings.Fields(cmd)
+	switch fields[0] {
+	case ".tables":
+		executeQuery(db, "select name from sqlite_master where type in ('table', 'view') order by name")
+	case ".schema":
+		if len(fields) > 1 {
+			executeQuery(db, "select sql from sqlite_master where name = :name and sql is not null", sql.Named("name", fields[1]))
+		} else {
+			executeQuery(db, "select sql from sqlite_master where sql is not null order by name")
+		}
+	case ".mode":
+		if len(fields) < 2 {
+			log.Fatalf(".mode requires a format argument")
+		}
+		if !validFormats[fields[1]] {
+			log.Fatalf("unknown .mode value %q", fields[1])
+		}
+		OutputFormat = fields[1]
+	default:
+		log.Fatalf("unknown dot-command %q", fields[0])
+	}
+}
+
+func executeQuery(db *sql.DB, query string, args ...interface{}) {
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		log.Fatalf("error executing query %#v database: %v", query, err)
 	}
@@ -102,73 +358,294 @@ func executeQuery(db *sql.DB, query string) {
 		log.Fatalf("error getting columns from query %#v: %v", query, err)
 	}
 
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		log.Fatalf("error getting column types from query %#v: %v", query, err)
+	}
+	typeNames := make([]string, len(columnTypes))
+	for i, ct := range columnTypes {
+		typeNames[i] = ct.DatabaseTypeName()
+	}
+
 	values := make([]sql.NullString, len(columns))
 	valuePtrs := make([]interface{}, len(columns))
 	for i := 0; i < len(columns); i++ {
 		valuePtrs[i] = &values[i]
 	}
 
-	writer := getWriter()
+	writer := newOutputWriter(OutputFormat, os.Stdout, columns, typeNames)
 	defer writer.Flush()
 
-	if HeadersOn {
-		writer.Write(columns)
-	}
-
 	for rows.Next() {
 		err := rows.Scan(valuePtrs...)
 		if err != nil {
 			log.Fatalf("error scanning rows: %v", err)
 		}
-		writer.Write(asStrings(values))
+		writer.Write(values)
 	}
 }
 
-func getWriter() OutputWriter {
-	if WritePlainText {
-		return NewPlainTextWriter(os.Stdout)
-	}
-	return NewCSVWriter(os.Stdout)
-}
-
+// OutputWriter renders query results in a particular output format. The
+// header (if any) and any format-specific framing are handled at
+// construction time or in Flush, so the caller only ever feeds it data rows.
 type OutputWriter interface {
-	Write([]string) error
+	Write([]sql.NullString) error
 	Flush()
 }
 
-type CSVWriter struct {
+func newOutputWriter(format string, w io.Writer, columns, columnTypes []string) OutputWriter {
+	switch format {
+	case "tsv":
+		return newDelimitedWriter(w, '\t', columns)
+	case "json":
+		return NewJSONWriter(w, columns, columnTypes, false)
+	case "jsonl":
+		return NewJSONWriter(w, columns, columnTypes, true)
+	case "markdown":
+		return NewMarkdownWriter(w, columns)
+	case "table":
+		return NewTableWriter(w, columns)
+	default:
+		return newDelimitedWriter(w, ',', columns)
+	}
+}
+
+// delimitedWriter renders csv and tsv output; the only difference between
+// the two is the separator passed to csv.Writer.
+type delimitedWriter struct {
 	*csv.Writer
 }
 
-func NewCSVWriter(w io.Writer) *CSVWriter {
-	return &CSVWriter{csv.NewWriter(w)}
+func newDelimitedWriter(w io.Writer, comma rune, columns []string) *delimitedWriter {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	dw := &delimitedWriter{cw}
+	if HeadersOn {
+		dw.Writer.Write(columns)
+	}
+	return dw
 }
 
-func (w *CSVWriter) Write(ss []string) error {
-	return w.Writer.Write(ss)
+func (w *delimitedWriter) Write(values []sql.NullString) error {
+	return w.Writer.Write(asStrings(values))
 }
 
-func (w *CSVWriter) Flush() {
+func (w *delimitedWriter) Flush() {
 	w.Writer.Flush()
 }
 
-type PlainTextWriter struct {
-	*bufio.Writer
+// JSONWriter emits an array of {column: value} objects, or one object per
+// line in jsonl mode. Values are typed using the query's column types so
+// integer/real columns come out as JSON numbers rather than strings, and a
+// NULL sql.NullString comes out as JSON null.
+type JSONWriter struct {
+	w           io.Writer
+	enc         *json.Encoder
+	columns     []string
+	columnTypes []string
+	lines       bool
+	rows        []orderedRow
 }
 
-func NewPlainTextWriter(w io.Writer) *PlainTextWriter {
-	return &PlainTextWriter{
-		Writer: bufio.NewWriter(w),
+func NewJSONWriter(w io.Writer, columns, columnTypes []string, lines bool) *JSONWriter {
+	jw := &JSONWriter{w: w, columns: columns, columnTypes: columnTypes, lines: lines}
+	if lines {
+		jw.enc = json.NewEncoder(w)
 	}
+	return jw
 }
 
-func (w *PlainTextWriter) Write(ss []string) error {
-	fmt.Fprintln(w.Writer, strings.Join(ss, "\t"))
+func (w *JSONWriter) Write(values []sql.NullString) error {
+	row := make(orderedRow, len(w.columns))
+	for i, col := range w.columns {
+		row[i] = jsonField{key: col, value: jsonValue(values[i], w.columnTypes[i])}
+	}
+	if w.lines {
+		return w.enc.Encode(row)
+	}
+	w.rows = append(w.rows, row)
 	return nil
 }
 
-func (w *PlainTextWriter) Flush() {
-	w.Writer.Flush()
+func (w *JSONWriter) Flush() {
+	if w.lines {
+		return
+	}
+	if w.rows == nil {
+		w.rows = []orderedRow{}
+	}
+	enc := json.NewEncoder(w.w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(w.rows); err != nil {
+		log.Fatalf("error encoding JSON output: %v", err)
+	}
+}
+
+// orderedRow renders as a JSON object with keys in column order. A plain Go
+// map would work too, but encoding/json always sorts map keys alphabetically,
+// which loses the query's column order.
+type orderedRow []jsonField
+
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+func (r orderedRow) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		val, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonValue converts a scanned column value to the Go value that encodes to
+// the right JSON type: a NULL becomes nil, and INTEGER/REAL columns become
+// int64/float64 when the value actually parses as one.
+func jsonValue(v sql.NullString, columnType string) interface{} {
+	if !v.Valid {
+		return nil
+	}
+	switch columnType {
+	case "INTEGER":
+		if n, err := strconv.ParseInt(v.String, 10, 64); err == nil {
+			return n
+		}
+	case "REAL":
+		if f, err := strconv.ParseFloat(v.String, 64); err == nil {
+			return f
+		}
+	}
+	return v.String
+}
+
+// MarkdownWriter streams a GitHub-flavored pipe table: a header row, a
+// "---" separator row, and one row per result row. Unlike TableWriter it
+// doesn't need to buffer, since markdown tables don't require aligned
+// column widths.
+type MarkdownWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func NewMarkdownWriter(w io.Writer, columns []string) *MarkdownWriter {
+	mw := &MarkdownWriter{w: bufio.NewWriter(w), columns: columns}
+	if HeadersOn {
+		mw.writeHeader()
+	}
+	return mw
+}
+
+func (w *MarkdownWriter) writeHeader() {
+	fmt.Fprintf(w.w, "| %s |\n", strings.Join(w.columns, " | "))
+	seps := make([]string, len(w.columns))
+	for i := range seps {
+		seps[i] = "---"
+	}
+	fmt.Fprintf(w.w, "| %s |\n", strings.Join(seps, " | "))
+}
+
+func (w *MarkdownWriter) Write(values []sql.NullString) error {
+	cells := make([]string, len(values))
+	for i, v := range values {
+		cells[i] = strings.ReplaceAll(v.String, "|", "\\|")
+	}
+	_, err := fmt.Fprintf(w.w, "| %s |\n", strings.Join(cells, " | "))
+	return err
+}
+
+func (w *MarkdownWriter) Flush() {
+	w.w.Flush()
+}
+
+// TableWriter renders an aligned ASCII grid. Column widths depend on every
+// cell in the result set, so rows are buffered and the whole grid is
+// written out in Flush.
+type TableWriter struct {
+	w       io.Writer
+	columns []string
+	rows    [][]string
+}
+
+func NewTableWriter(w io.Writer, columns []string) *TableWriter {
+	return &TableWriter{w: w, columns: columns}
+}
+
+func (w *TableWriter) Write(values []sql.NullString) error {
+	w.rows = append(w.rows, asStrings(values))
+	return nil
+}
+
+func (w *TableWriter) Flush() {
+	widths := make([]int, len(w.columns))
+	if HeadersOn {
+		for i, c := range w.columns {
+			widths[i] = utf8.RuneCountInString(c)
+		}
+	}
+	for _, row := range w.rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				continue
+			}
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w.w)
+	defer bw.Flush()
+
+	if HeadersOn {
+		writeTableRow(bw, w.columns, widths)
+		writeTableSeparator(bw, widths)
+	}
+	for _, row := range w.rows {
+		writeTableRow(bw, row, widths)
+	}
+}
+
+func writeTableRow(w *bufio.Writer, cells []string, widths []int) {
+	padded := make([]string, len(widths))
+	for i := range widths {
+		cell := ""
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		padded[i] = padRight(cell, widths[i])
+	}
+	fmt.Fprintln(w, strings.Join(padded, "  "))
+}
+
+func writeTableSeparator(w *bufio.Writer, widths []int) {
+	seps := make([]string, len(widths))
+	for i, width := range widths {
+		seps[i] = strings.Repeat("-", width)
+	}
+	fmt.Fprintln(w, strings.Join(seps, "  "))
+}
+
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
 }
 
 func asStrings(ss []sql.NullString) []string {
@@ -180,10 +657,24 @@ func asStrings(ss []sql.NullString) []string {
 }
 
 func sqlNameFromString(path string) string {
-	path = strings.TrimSuffix(path, ".csv")
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	if i := strings.Index(path, "://"); i >= 0 {
+		path = path[i+3:]
+	}
 	// get the last part of the path
 	parts := strings.Split(path, "/")
 	path = parts[len(parts)-1]
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, ext := range []string{".csv", ".gz", ".zst", ".bz2", ".zip"} {
+			if strings.HasSuffix(path, ext) {
+				path = strings.TrimSuffix(path, ext)
+				trimmed = true
+			}
+		}
+	}
 	// replace all non-alphanumeric characters with underscores
 	path = strings.Map(func(r rune) rune {
 		if unicode.IsLetter(r) || unicode.IsNumber(r) {
@@ -194,31 +685,292 @@ func sqlNameFromString(path string) string {
 	return path
 }
 
-func createTableFromCSV(db *sql.DB, tableName, fileName string) {
+// isCSVInputArg reports whether arg names something createTableFromCSV
+// should ingest: a local (optionally compressed/zipped) CSV file, or a
+// remote CSV fetched over http(s) or s3.
+func isCSVInputArg(arg string) bool {
+	lower := strings.ToLower(arg)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "s3://") {
+		return true
+	}
+	for _, suffix := range []string{".csv", ".csv.gz", ".csv.zst", ".csv.bz2", ".zip"} {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func createTableFromCSV(db *sql.DB, tableName, spec string) {
+	if VirtualMode {
+		createVirtualTableFromCSV(db, tableName, spec)
+		return
+	}
+
+	input, name, err := openInput(spec)
+	if err != nil {
+		log.Fatalf("error opening input %s: %v", spec, err)
+	}
+	defer input.Close()
+
+	createTableFromReader(db, tableName, input, name)
+}
+
+// openInput resolves spec — a local path, an http(s) URL, or an s3://
+// URL — to a readable stream, transparently decompressing .gz/.zst/.bz2
+// and picking a CSV member out of a .zip. The returned name is spec with
+// any scheme/compression/zip-member decoration stripped, for error messages
+// and table-name derivation.
+func openInput(spec string) (io.ReadCloser, string, error) {
+	switch {
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return openHTTPInput(spec)
+	case strings.HasPrefix(spec, "s3://"):
+		return openS3Input(spec)
+	default:
+		return openLocalInput(spec)
+	}
+}
+
+func openLocalInput(path string) (io.ReadCloser, string, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		return openLocalZip(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return wrapCompressed(f, path)
+}
+
+func openHTTPInput(url string) (io.ReadCloser, string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("unexpected status %s fetching %s", resp.Status, url)
+	}
+	if strings.HasSuffix(strings.ToLower(url), ".zip") {
+		return openBufferedZip(resp.Body, url)
+	}
+	return wrapCompressed(resp.Body, url)
+}
+
+// wrapCompressed peels a .gz/.zst/.bz2 layer off r based on name, closing
+// the underlying reader along with the decompressor.
+func wrapCompressed(r io.ReadCloser, name string) (io.ReadCloser, string, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".gz"):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			r.Close()
+			return nil, "", err
+		}
+		return &multiCloseReader{Reader: gz, closers: []io.Closer{gz, r}}, stripCompressionSuffix(name), nil
+	case strings.HasSuffix(lower, ".zst"):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			r.Close()
+			return nil, "", err
+		}
+		return &zstdReadCloser{Decoder: zr, underlying: r}, stripCompressionSuffix(name), nil
+	case strings.HasSuffix(lower, ".bz2"):
+		return &multiCloseReader{Reader: bzip2.NewReader(r), closers: []io.Closer{r}}, stripCompressionSuffix(name), nil
+	default:
+		return r, name, nil
+	}
+}
+
+func stripCompressionSuffix(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".gz", ".zst", ".bz2"} {
+		if strings.HasSuffix(lower, ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// multiCloseReader reads from a single Reader but closes a chain of
+// Closers (e.g. a gzip.Reader wrapping an *os.File) in order.
+type multiCloseReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloseReader) Close() error {
+	var firstErr error
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// zstdReadCloser adapts a *zstd.Decoder, whose Close method has no error
+// return, to io.ReadCloser, and also closes the underlying stream.
+type zstdReadCloser struct {
+	*zstd.Decoder
+	underlying io.Closer
+}
 
-	csvFile, err := os.Open(fileName)
+func (z *zstdReadCloser) Close() error {
+	z.Decoder.Close()
+	return z.underlying.Close()
+}
+
+// openLocalZip opens a local .zip file and selects a CSV member out of it.
+func openLocalZip(path string) (io.ReadCloser, string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, "", err
+	}
+	member, err := selectZipMember(zr.File)
+	if err != nil {
+		zr.Close()
+		return nil, "", err
+	}
+	rc, err := member.Open()
+	if err != nil {
+		zr.Close()
+		return nil, "", err
+	}
+	return &multiCloseReader{Reader: rc, closers: []io.Closer{rc, zr}}, member.Name, nil
+}
+
+// openBufferedZip selects a CSV member out of a .zip read from body.
+// archive/zip needs an io.ReaderAt, so a remote zip has to be buffered in
+// full before a member can be opened.
+func openBufferedZip(body io.ReadCloser, name string) (io.ReadCloser, string, error) {
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, "", err
+	}
+	member, err := selectZipMember(zr.File)
 	if err != nil {
-		log.Fatalf("error opening the CSV file %s: %v", fileName, err)
+		return nil, "", err
 	}
-	defer csvFile.Close()
+	rc, err := member.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	return rc, member.Name, nil
+}
 
-	createTableFromReader(db, tableName, csvFile, fileName)
+// selectZipMember picks the --zip-member= override if one was given,
+// otherwise the first member with a .csv extension.
+func selectZipMember(files []*zip.File) (*zip.File, error) {
+	if ZipMember != "" {
+		for _, f := range files {
+			if f.Name == ZipMember {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("zip member %q not found", ZipMember)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(strings.ToLower(f.Name), ".csv") {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("zip archive contains no .csv member (use --zip-member= to pick one)")
 }
 
 func createTableFromReader(db *sql.DB, tableName string, input io.Reader, fileName string) {
 
-	reader := csv.NewReader(input)
+	br := bufio.NewReaderSize(input, SniffBytes+1)
+	sample, _ := br.Peek(SniffBytes)
+
+	enc := detectEncoding(sample)
+	if EncodingOverride != "" {
+		var err error
+		enc, err = encodingByName(EncodingOverride)
+		if err != nil {
+			log.Fatalf("invalid --encoding value: %v", err)
+		}
+	}
+	sampleText, err := enc.NewDecoder().String(string(sample))
+	if err != nil {
+		sampleText = string(sample)
+	}
+
+	delim := detectDelimiter(sampleText)
+	if DelimOverride != "" {
+		delim = parseDelim(DelimOverride)
+	}
+
+	// inputHasHeader decides whether this file's first row is a header,
+	// independent of HeadersOn (which only controls whether query output
+	// prints a header of its own).
+	var inputHasHeader bool
+	if headersOverride != nil {
+		inputHasHeader = *headersOverride
+	} else {
+		inputHasHeader = detectHeaderRow(sampleText, delim)
+	}
+
+	reader := csv.NewReader(transform.NewReader(br, enc.NewDecoder()))
+	reader.Comma = delim
 	reader.FieldsPerRecord = -1
 	reader.TrimLeadingSpace = true
 	reader.LazyQuotes = true
 
-	records, err := reader.ReadAll()
-	if err != nil {
-		log.Fatalf("failed to read all of CSV from %s: %v", fileName, err)
+	// When there's no header row, the first record still has to be read to
+	// know the column count, so it becomes the first buffered data row
+	// instead of being discarded.
+	var fieldNames []string
+	var firstDataRow []string
+	if inputHasHeader {
+		header, err := reader.Read()
+		if err != nil {
+			log.Fatalf("failed to read header from %s: %v", fileName, err)
+		}
+		fieldNames = mapStrings(header, sqlNameFromString)
+	} else {
+		first, err := reader.Read()
+		if err != nil {
+			log.Fatalf("failed to read first record from %s: %v", fileName, err)
+		}
+		fieldNames = generatedFieldNames(len(first))
+		firstDataRow = first
 	}
 
-	fieldNames := mapStrings(records[0], sqlNameFromString)
 	fieldCount := len(fieldNames)
+	fieldTypes := columnTypes(fieldNames)
+
+	var buffered [][]string
+	if firstDataRow != nil {
+		buffered = append(buffered, firstDataRow)
+	}
+
+	// Sampling for type inference needs the data rows in hand before CREATE
+	// TABLE runs. When --sample-rows is given we only buffer that many rows
+	// and keep streaming the rest below; with no limit we buffer the whole
+	// file, trading the streaming benefit for exact inference.
+	if InferTypes {
+		if SampleRows > 0 {
+			if remaining := SampleRows - len(buffered); remaining > 0 {
+				buffered = append(buffered, readUpTo(reader, fileName, remaining)...)
+			}
+		} else {
+			buffered = append(buffered, readUpTo(reader, fileName, 0)...)
+		}
+		inferred := inferColumnTypes(fieldNames, buffered)
+		for i, t := range inferred {
+			if fieldTypes[i] == "" {
+				fieldTypes[i] = t
+			}
+		}
+	}
 
 	if Replacing {
 		_, err = db.Exec(fmt.Sprintf("drop table if exists %s", tableName))
@@ -227,7 +979,7 @@ func createTableFromReader(db *sql.DB, tableName string, input io.Reader, fileNa
 		}
 	}
 
-	createTableStmt := fmt.Sprintf("create table %s (%s)", tableName, strings.Join(fieldNames, ", "))
+	createTableStmt := fmt.Sprintf("create table %s (%s)", tableName, strings.Join(columnDefs(fieldNames, fieldTypes), ", "))
 	_, err = db.Exec(createTableStmt)
 	if err != nil {
 		if strings.Contains(err.Error(), "already exists") {
@@ -237,23 +989,409 @@ func createTableFromReader(db *sql.DB, tableName string, input io.Reader, fileNa
 		}
 	}
 
-	insertStmt := fmt.Sprintf("insert into %s (%s) values (%s)", tableName,
+	insertSQL := fmt.Sprintf("insert into %s (%s) values (%s)", tableName,
 		strings.Join(fieldNames, ", "),
 		strings.TrimRight(strings.Repeat("?, ", fieldCount), ", "))
 
-	for _, record := range records[1:] {
-		values := make([]interface{}, 0, fieldCount)
-		for _, v := range record {
-			values = append(values, v)
+	batch := newBatchInserter(db, insertSQL, fieldCount, fieldTypes, BatchSize)
+
+	for _, record := range buffered {
+		batch.add(record)
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
 		}
-		for i := len(record); i < fieldCount; i++ {
-			values = append(values, nil)
+		if err != nil {
+			log.Fatalf("failed to read CSV record from %s: %v", fileName, err)
+		}
+		batch.add(record)
+	}
+
+	batch.finish()
+}
+
+// generatedFieldNames synthesizes "col1".."colN" names for a headerless CSV.
+func generatedFieldNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("col%d", i+1)
+	}
+	return names
+}
+
+var delimCandidates = []rune{',', '\t', ';', '|'}
+
+// detectDelimiter picks whichever of the candidate delimiters splits the
+// sniffed sample into the most consistent number of fields per line.
+func detectDelimiter(sample string) rune {
+	lines := strings.Split(sample, "\n")
+	if len(lines) > 1 {
+		lines = lines[:len(lines)-1] // the sniff window may cut the last line in half
+	}
+	if len(lines) > 50 {
+		lines = lines[:50]
+	}
+
+	best := delimCandidates[0]
+	bestScore := -1
+	for _, d := range delimCandidates {
+		if score := delimiterConsistency(lines, d); score > bestScore {
+			bestScore = score
+			best = d
+		}
+	}
+	return best
+}
+
+// delimiterConsistency counts how many lines share the most common field
+// count produced by splitting on d; higher means d is more likely the real
+// delimiter. Lines with only one field (d never appears) don't count.
+func delimiterConsistency(lines []string, d rune) int {
+	counts := map[int]int{}
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		if n := strings.Count(line, string(d)) + 1; n > 1 {
+			counts[n]++
+		}
+	}
+	best := 0
+	for _, c := range counts {
+		if c > best {
+			best = c
+		}
+	}
+	return best
+}
+
+func parseDelim(spec string) rune {
+	switch spec {
+	case "\\t", "tab":
+		return '\t'
+	}
+	r := []rune(spec)
+	if len(r) != 1 {
+		log.Fatalf("invalid --delim value %q: expected a single character", spec)
+	}
+	return r[0]
+}
+
+// detectHeaderRow looks for positive evidence that row 0 is itself a data
+// row: a column that's numeric in row 0 and numeric in a later row too,
+// which a real header name wouldn't be. Absent that evidence, it assumes
+// there is a header, since most CSVs have one.
+func detectHeaderRow(sample string, delim rune) bool {
+	rows := sniffRows(sample, delim)
+	if len(rows) < 2 {
+		return true
+	}
+
+	header := rows[0]
+	for _, row := range rows[1:] {
+		for i, value := range row {
+			if i < len(header) && isNumericField(value) && isNumericField(header[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sniffRows(sample string, delim rune) [][]string {
+	r := csv.NewReader(strings.NewReader(sample))
+	r.Comma = delim
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.LazyQuotes = true
+
+	var rows [][]string
+	for i := 0; i < 20; i++ {
+		row, err := r.Read()
+		if err != nil {
+			break
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func isNumericField(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+var utf16LEBOM = []byte{0xFF, 0xFE}
+var utf16BEBOM = []byte{0xFE, 0xFF}
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// detectEncoding sniffs a byte sample for a BOM, and failing that guesses
+// UTF-8, then GBK, then falls back to Latin-1, which accepts any byte
+// sequence and so is never wrong in the sense of failing to decode.
+func detectEncoding(sample []byte) encoding.Encoding {
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		return textunicode.UTF8
+	case bytes.HasPrefix(sample, utf16LEBOM):
+		return textunicode.UTF16(textunicode.LittleEndian, textunicode.UseBOM)
+	case bytes.HasPrefix(sample, utf16BEBOM):
+		return textunicode.UTF16(textunicode.BigEndian, textunicode.UseBOM)
+	}
+	if utf8.Valid(sample) {
+		return textunicode.UTF8
+	}
+	if decoded, err := simplifiedchinese.GBK.NewDecoder().Bytes(sample); err == nil && utf8.Valid(decoded) {
+		return simplifiedchinese.GBK
+	}
+	return charmap.ISO8859_1
+}
+
+func encodingByName(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "utf-8", "utf8":
+		return textunicode.UTF8, nil
+	case "utf-16le", "utf16le":
+		return textunicode.UTF16(textunicode.LittleEndian, textunicode.IgnoreBOM), nil
+	case "utf-16be", "utf16be":
+		return textunicode.UTF16(textunicode.BigEndian, textunicode.IgnoreBOM), nil
+	case "latin1", "iso-8859-1", "iso8859-1":
+		return charmap.ISO8859_1, nil
+	case "gbk":
+		return simplifiedchinese.GBK, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", name)
+	}
+}
+
+// readUpTo reads at most n data rows from reader, or every remaining row
+// when n is 0.
+func readUpTo(reader *csv.Reader, fileName string, n int) [][]string {
+	var rows [][]string
+	for n <= 0 || len(rows) < n {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
 		}
-		_, err := db.Exec(insertStmt, values...)
 		if err != nil {
-			log.Fatalf("error inserting record: %v", err)
+			log.Fatalf("failed to read CSV record from %s: %v", fileName, err)
+		}
+		rows = append(rows, record)
+	}
+	return rows
+}
+
+// batchInserter prepares the INSERT once and commits it in batches inside
+// explicit transactions, instead of issuing one db.Exec per row.
+type batchInserter struct {
+	db         *sql.DB
+	insertSQL  string
+	fieldCount int
+	fieldTypes []string
+	batchSize  int
+
+	tx      *sql.Tx
+	stmt    *sql.Stmt
+	pending int
+}
+
+func newBatchInserter(db *sql.DB, insertSQL string, fieldCount int, fieldTypes []string, batchSize int) *batchInserter {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	b := &batchInserter{
+		db:         db,
+		insertSQL:  insertSQL,
+		fieldCount: fieldCount,
+		fieldTypes: fieldTypes,
+		batchSize:  batchSize,
+	}
+	b.startTx()
+	return b
+}
+
+func (b *batchInserter) startTx() {
+	tx, err := b.db.Begin()
+	if err != nil {
+		log.Fatalf("error beginning transaction: %v", err)
+	}
+	stmt, err := tx.Prepare(b.insertSQL)
+	if err != nil {
+		log.Fatalf("error preparing insert statement: %v", err)
+	}
+	b.tx = tx
+	b.stmt = stmt
+}
+
+func (b *batchInserter) add(record []string) {
+	if len(record) != b.fieldCount {
+		log.Printf("record has %d fields, want %d; truncating or padding with NULL", len(record), b.fieldCount)
+	}
+
+	values := make([]interface{}, 0, b.fieldCount)
+	for i := 0; i < b.fieldCount && i < len(record); i++ {
+		values = append(values, typedValue(record[i], b.fieldTypes[i]))
+	}
+	for i := len(record); i < b.fieldCount; i++ {
+		values = append(values, nil)
+	}
+
+	if _, err := b.stmt.Exec(values...); err != nil {
+		log.Fatalf("error inserting record: %v", err)
+	}
+
+	b.pending++
+	if b.pending >= b.batchSize {
+		b.commit()
+		b.startTx()
+	}
+}
+
+func (b *batchInserter) commit() {
+	if err := b.stmt.Close(); err != nil {
+		log.Fatalf("error closing prepared statement: %v", err)
+	}
+	if err := b.tx.Commit(); err != nil {
+		log.Fatalf("error committing transaction: %v", err)
+	}
+	b.pending = 0
+}
+
+func (b *batchInserter) finish() {
+	b.commit()
+}
+
+// setBulkLoadPragmas relaxes durability in exchange for load speed. It's
+// only safe for a fresh import, which is what --db is used for.
+func setBulkLoadPragmas(db *sql.DB) {
+	for _, pragma := range []string{"PRAGMA journal_mode=MEMORY", "PRAGMA synchronous=OFF"} {
+		if _, err := db.Exec(pragma); err != nil {
+			log.Fatalf("error setting %s: %v", pragma, err)
+		}
+	}
+}
+
+// columnDefs renders "name" or "name TYPE" for each field, depending on
+// whether a type was inferred or given for that column.
+func columnDefs(fieldNames, fieldTypes []string) []string {
+	defs := make([]string, len(fieldNames))
+	for i, name := range fieldNames {
+		if fieldTypes[i] == "" {
+			defs[i] = name
+		} else {
+			defs[i] = name + " " + fieldTypes[i]
+		}
+	}
+	return defs
+}
+
+// columnTypes returns the user-specified --schema override for each field,
+// or "" where no override was given.
+func columnTypes(fieldNames []string) []string {
+	types := make([]string, len(fieldNames))
+	for i, name := range fieldNames {
+		types[i] = SchemaOverride[name]
+	}
+	return types
+}
+
+// parseSchemaOverride parses a "col1:INT,col2:TEXT" spec into SchemaOverride,
+// normalizing common type aliases to their SQLite affinity name.
+func parseSchemaOverride(spec string) {
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			log.Fatalf("invalid --schema entry %q, expected col:TYPE", pair)
+		}
+		SchemaOverride[sqlNameFromString(parts[0])] = normalizeType(parts[1])
+	}
+}
+
+func normalizeType(t string) string {
+	switch strings.ToUpper(strings.TrimSpace(t)) {
+	case "INT", "INTEGER":
+		return "INTEGER"
+	case "REAL", "FLOAT", "DOUBLE":
+		return "REAL"
+	case "DATE", "DATETIME":
+		return "DATE"
+	default:
+		return "TEXT"
+	}
+}
+
+// inferColumnTypes scans a sample of the data rows and picks INTEGER, REAL,
+// or TEXT for each column. The guess is strict-monotonic: once a column's
+// values fail to parse as INTEGER it is downgraded to REAL, and once REAL
+// fails it is downgraded to TEXT; it never upgrades back. Empty strings are
+// treated as NULL and don't influence the guess.
+func inferColumnTypes(fieldNames []string, dataRows [][]string) []string {
+	types := make([]string, len(fieldNames))
+	for i := range types {
+		types[i] = "INTEGER"
+	}
+
+	rows := dataRows
+	if SampleRows > 0 && SampleRows < len(rows) {
+		rows = rows[:SampleRows]
+	}
+
+	for _, record := range rows {
+		for i := range types {
+			if i >= len(record) {
+				continue
+			}
+			value := strings.TrimSpace(record[i])
+			if value == "" {
+				continue
+			}
+			switch types[i] {
+			case "INTEGER":
+				if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+					if _, err := strconv.ParseFloat(value, 64); err != nil {
+						types[i] = "TEXT"
+					} else {
+						types[i] = "REAL"
+					}
+				}
+			case "REAL":
+				if _, err := strconv.ParseFloat(value, 64); err != nil {
+					types[i] = "TEXT"
+				}
+			}
+		}
+	}
+
+	return types
+}
+
+// typedValue converts a raw CSV field into the Go value that matches the
+// column's declared type, so SQLite stores it with the right affinity
+// instead of as a string. Empty strings become NULL.
+func typedValue(raw, columnType string) interface{} {
+	if raw == "" {
+		return nil
+	}
+	switch columnType {
+	case "INTEGER":
+		if v, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return v
+		}
+	case "REAL":
+		if v, err := strconv.ParseFloat(raw, 64); err == nil {
+			return v
 		}
 	}
+	return raw
 }
 
 func mapStrings(ss []string, f func(string) string) []string {
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	textunicode "golang.org/x/text/encoding/unicode"
+)
+
+func TestInferColumnTypes(t *testing.T) {
+	cases := []struct {
+		name     string
+		fields   []string
+		rows     [][]string
+		expected []string
+	}{
+		{
+			name:     "all integer",
+			fields:   []string{"a", "b"},
+			rows:     [][]string{{"1", "2"}, {"3", "4"}},
+			expected: []string{"INTEGER", "INTEGER"},
+		},
+		{
+			name:     "integer downgrades to real on a float value",
+			fields:   []string{"a"},
+			rows:     [][]string{{"1"}, {"2.5"}},
+			expected: []string{"REAL"},
+		},
+		{
+			name:     "integer downgrades straight to text, skipping real",
+			fields:   []string{"a"},
+			rows:     [][]string{{"1"}, {"foo"}},
+			expected: []string{"TEXT"},
+		},
+		{
+			name:     "real never upgrades back to integer",
+			fields:   []string{"a"},
+			rows:     [][]string{{"2.5"}, {"3"}},
+			expected: []string{"REAL"},
+		},
+		{
+			name:     "text never downgrades back once reached",
+			fields:   []string{"a"},
+			rows:     [][]string{{"foo"}, {"3"}},
+			expected: []string{"TEXT"},
+		},
+		{
+			name:     "blank values are skipped, not treated as text",
+			fields:   []string{"a"},
+			rows:     [][]string{{""}, {"1"}},
+			expected: []string{"INTEGER"},
+		},
+		{
+			name:     "short rows leave later columns at their default",
+			fields:   []string{"a", "b"},
+			rows:     [][]string{{"1"}},
+			expected: []string{"INTEGER", "INTEGER"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := inferColumnTypes(c.fields, c.rows)
+			if !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("inferColumnTypes(%v, %v) = %v, want %v", c.fields, c.rows, got, c.expected)
+			}
+		})
+	}
+}
+
+func TestSplitSQLStatements(t *testing.T) {
+	cases := []struct {
+		name     string
+		script   string
+		expected []string
+	}{
+		{
+			name:     "simple statements split on semicolons",
+			script:   "select 1; select 2;",
+			expected: []string{"select 1", " select 2"},
+		},
+		{
+			name:     "semicolon inside a single-quoted string doesn't split",
+			script:   "insert into t values ('a;b'); select 1;",
+			expected: []string{"insert into t values ('a;b')", " select 1"},
+		},
+		{
+			name:     "escaped single quote inside a string is not a string terminator",
+			script:   "select 'it''s; a test';",
+			expected: []string{"select 'it''s; a test'"},
+		},
+		{
+			name:     "semicolon inside a double-quoted string doesn't split",
+			script:   `select "a;b";`,
+			expected: []string{`select "a;b"`},
+		},
+		{
+			name:     "semicolon inside a line comment doesn't split",
+			script:   "select 1; -- comment; with a semicolon\nselect 2;",
+			expected: []string{"select 1", " select 2"},
+		},
+		{
+			name:     "semicolon inside a block comment doesn't split",
+			script:   "select 1; /* comment; with a semicolon */ select 2;",
+			expected: []string{"select 1", "  select 2"},
+		},
+		{
+			name:     "trailing statement without a final semicolon is kept",
+			script:   "select 1; select 2",
+			expected: []string{"select 1", " select 2"},
+		},
+		{
+			name:     "a dot-command runs to end of line, not to a semicolon",
+			script:   ".mode json\nselect 1;",
+			expected: []string{".mode json", "select 1"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := splitSQLStatements(c.script)
+			if !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("splitSQLStatements(%q) = %#v, want %#v", c.script, got, c.expected)
+			}
+		})
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	cases := []struct {
+		name     string
+		sample   string
+		expected rune
+	}{
+		{"comma", "a,b,c\n1,2,3\n4,5,6\n", ','},
+		{"tab", "a\tb\tc\n1\t2\t3\n4\t5\t6\n", '\t'},
+		{"semicolon", "a;b;c\n1;2;3\n4;5;6\n", ';'},
+		{"pipe", "a|b|c\n1|2|3\n4|5|6\n", '|'},
+		{"defaults to comma with no delimiter evidence", "single\nfield\n", ','},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := detectDelimiter(c.sample); got != c.expected {
+				t.Errorf("detectDelimiter(%q) = %q, want %q", c.sample, got, c.expected)
+			}
+		})
+	}
+}
+
+func TestDetectHeaderRow(t *testing.T) {
+	cases := []struct {
+		name     string
+		sample   string
+		expected bool
+	}{
+		{"all-text columns default to has-header", "name,city,country\nalice,nyc,us\nbob,ldn,uk\n", true},
+		{"numeric header column matches numeric data column", "1,2,3\n4,5,6\n", false},
+		{"mixed columns still detect the text-only header", "name,age\nalice,30\nbob,25\n", true},
+		{"too few rows to compare defaults to has-header", "a,b,c\n", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := detectHeaderRow(c.sample, ','); got != c.expected {
+				t.Errorf("detectHeaderRow(%q) = %v, want %v", c.sample, got, c.expected)
+			}
+		})
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	cases := []struct {
+		name     string
+		sample   []byte
+		expected encoding.Encoding
+	}{
+		{"utf-8 BOM", append([]byte{0xEF, 0xBB, 0xBF}, "a,b,c"...), textunicode.UTF8},
+		{"utf-16 LE BOM", append([]byte{0xFF, 0xFE}, "a,b,c"...), textunicode.UTF16(textunicode.LittleEndian, textunicode.UseBOM)},
+		{"utf-16 BE BOM", append([]byte{0xFE, 0xFF}, "a,b,c"...), textunicode.UTF16(textunicode.BigEndian, textunicode.UseBOM)},
+		{"valid utf-8 without a BOM", []byte("a,b,c\n1,2,3\n"), textunicode.UTF8},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := detectEncoding(c.sample); !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("detectEncoding(%q) = %v, want %v", c.sample, got, c.expected)
+			}
+		})
+	}
+
+	t.Run("invalid utf-8 falls back to GBK or Latin-1", func(t *testing.T) {
+		// A byte sequence that isn't valid UTF-8; the exact fallback
+		// (GBK vs Latin-1) depends on whether it happens to decode as GBK,
+		// but it must be one of the two, never UTF-8.
+		sample := []byte{0xA1, 0xA1, 0x80, 0x81, 0xFF}
+		got := detectEncoding(sample)
+		if reflect.DeepEqual(got, textunicode.UTF8) {
+			t.Errorf("detectEncoding(%v) returned UTF-8 for invalid UTF-8 input", sample)
+		}
+		if !reflect.DeepEqual(got, simplifiedchinese.GBK) && !reflect.DeepEqual(got, charmap.ISO8859_1) {
+			t.Errorf("detectEncoding(%v) = %v, want GBK or Latin-1", sample, got)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+//go:build s3
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func openS3Input(spec string) (io.ReadCloser, string, error) {
+	bucket, key, err := parseS3Spec(spec)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, "", fmt.Errorf("error loading AWS config: %w", err)
+	}
+
+	out, err := s3.NewFromConfig(cfg).GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error fetching %s: %w", spec, err)
+	}
+	if strings.HasSuffix(strings.ToLower(key), ".zip") {
+		return openBufferedZip(out.Body, key)
+	}
+	return wrapCompressed(out.Body, key)
+}
+
+func parseS3Spec(spec string) (bucket, key string, err error) {
+	rest := strings.TrimPrefix(spec, "s3://")
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid s3 spec %q, expected s3://bucket/key", spec)
+	}
+	return parts[0], parts[1], nil
+}
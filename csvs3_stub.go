@@ -0,0 +1,15 @@
+//go:build !s3
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// The AWS SDK (config + STS/credentials + the S3 client) is a heavy
+// dependency for what's otherwise an optional convenience, so s3:// support
+// is only compiled in with -tags s3; see csvs3.go.
+func openS3Input(spec string) (io.ReadCloser, string, error) {
+	return nil, "", fmt.Errorf("s3:// input requires building with -tags s3 (pulls in the AWS SDK)")
+}
@@ -0,0 +1,219 @@
+//go:build sqlite_vtable
+
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func registerCSVModule(conn *sqlite3.SQLiteConn) error {
+	return conn.CreateModule("csv", csvModule{})
+}
+
+// createVirtualTableFromCSV registers spec as a "csv" virtual table instead
+// of copying its rows, so queries read straight off the file on disk. The
+// csv module only understands plain local files, not the compressed/remote
+// inputs openInput handles.
+func createVirtualTableFromCSV(db *sql.DB, tableName, spec string) {
+	if strings.Contains(spec, "://") || !strings.HasSuffix(strings.ToLower(spec), ".csv") {
+		log.Fatalf("--virtual only supports local .csv files, got %q", spec)
+	}
+
+	// Unlike the copy path, a virtual table has no sniffed sample to
+	// auto-detect a header row from, so this only honors an explicit
+	// --headers/--no-headers and otherwise defaults to true.
+	headerOn := headersOverride == nil || *headersOverride
+	header := 0
+	if headerOn {
+		header = 1
+	}
+	stmt := fmt.Sprintf("create virtual table %s using csv(filename=%s, header=%d)",
+		tableName, quoteSQLLiteral(spec), header)
+	if _, err := db.Exec(stmt); err != nil {
+		log.Fatalf("error creating virtual table %s: %v", tableName, err)
+	}
+}
+
+func quoteSQLLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// csvModule implements sqlite3.Module for "CREATE VIRTUAL TABLE x USING
+// csv(filename='...', header=1)", exposing a CSV file as a table backed
+// directly by the file on disk rather than by copied rows.
+type csvModule struct{}
+
+func (csvModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	return newCSVVTab(c, args)
+}
+
+func (csvModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	return newCSVVTab(c, args)
+}
+
+func (csvModule) DestroyModule() {}
+
+func newCSVVTab(c *sqlite3.SQLiteConn, args []string) (*csvVTab, error) {
+	params := parseVTabArgs(args[3:])
+
+	filename, ok := params["filename"]
+	if !ok {
+		return nil, fmt.Errorf("csv virtual table requires filename=...")
+	}
+	headerOn := params["header"] != "0"
+
+	columns, err := readCSVColumns(filename, headerOn)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := fmt.Sprintf("create table x(%s)", strings.Join(columns, ", "))
+	if err := c.DeclareVTab(schema); err != nil {
+		return nil, err
+	}
+
+	return &csvVTab{filename: filename, headerOn: headerOn, columns: columns}, nil
+}
+
+// parseVTabArgs parses the "key='value'" / "key=value" arguments SQLite
+// passes a virtual table module after the module/db/table name.
+func parseVTabArgs(args []string) map[string]string {
+	params := map[string]string{}
+	for _, arg := range args {
+		parts := strings.SplitN(strings.TrimSpace(arg), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.Trim(strings.TrimSpace(parts[1]), `'"`)
+		params[key] = value
+	}
+	return params
+}
+
+// readCSVColumns peeks the first record of filename to derive column names,
+// without holding the file open past this call.
+func readCSVColumns(filename string, headerOn bool) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.LazyQuotes = true
+
+	row, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	if headerOn {
+		return mapStrings(row, sqlNameFromString), nil
+	}
+	return generatedFieldNames(len(row)), nil
+}
+
+type csvVTab struct {
+	filename string
+	headerOn bool
+	columns  []string
+}
+
+func (v *csvVTab) Open() (sqlite3.VTabCursor, error) {
+	return &csvVTabCursor{vtab: v}, nil
+}
+
+// BestIndex has no indexes to offer; the cursor always does a full scan.
+func (v *csvVTab) BestIndex([]sqlite3.InfoConstraint, []sqlite3.InfoOrderBy) (*sqlite3.IndexResult, error) {
+	return &sqlite3.IndexResult{}, nil
+}
+
+func (v *csvVTab) Disconnect() error { return nil }
+func (v *csvVTab) Destroy() error    { return nil }
+
+// csvVTabCursor reads csvVTab.filename directly; Filter (re)opens the file
+// and seeks back to the first data row, and Next reads one record at a time
+// so a query never has the whole file in memory.
+type csvVTabCursor struct {
+	vtab   *csvVTab
+	file   *os.File
+	reader *csv.Reader
+	row    []string
+	rowid  int64
+	eof    bool
+}
+
+func (c *csvVTabCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
+	if c.file != nil {
+		c.file.Close()
+	}
+
+	f, err := os.Open(c.vtab.filename)
+	if err != nil {
+		return err
+	}
+	c.file = f
+
+	c.reader = csv.NewReader(f)
+	c.reader.FieldsPerRecord = -1
+	c.reader.TrimLeadingSpace = true
+	c.reader.LazyQuotes = true
+	if c.vtab.headerOn {
+		if _, err := c.reader.Read(); err != nil && err != io.EOF {
+			return err
+		}
+	}
+
+	c.rowid = 0
+	c.eof = false
+	return c.Next()
+}
+
+func (c *csvVTabCursor) Next() error {
+	row, err := c.reader.Read()
+	if err == io.EOF {
+		c.eof = true
+		c.row = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.row = row
+	c.rowid++
+	return nil
+}
+
+func (c *csvVTabCursor) EOF() bool {
+	return c.eof
+}
+
+func (c *csvVTabCursor) Column(ctx *sqlite3.SQLiteContext, col int) error {
+	if col < 0 || col >= len(c.row) {
+		ctx.ResultNull()
+		return nil
+	}
+	ctx.ResultText(c.row[col])
+	return nil
+}
+
+func (c *csvVTabCursor) Rowid() (int64, error) {
+	return c.rowid, nil
+}
+
+func (c *csvVTabCursor) Close() error {
+	if c.file != nil {
+		return c.file.Close()
+	}
+	return nil
+}
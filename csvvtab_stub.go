@@ -0,0 +1,23 @@
+//go:build !sqlite_vtable
+
+package main
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// go-sqlite3's virtual-table support is itself gated behind the
+// sqlite_vtable build tag, so the real "csv" module in csvvtab.go only
+// compiles in with that tag. Without it, --virtual fails clearly instead
+// of silently falling back to copying rows.
+
+func registerCSVModule(conn *sqlite3.SQLiteConn) error {
+	return nil
+}
+
+func createVirtualTableFromCSV(db *sql.DB, tableName, spec string) {
+	log.Fatalf("--virtual requires building with -tags sqlite_vtable (go-sqlite3's virtual table support is build-tag gated)")
+}